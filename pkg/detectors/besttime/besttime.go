@@ -3,14 +3,13 @@ package besttime
 import (
 	"context"
 	"io/ioutil"
-
-	// "log"
+	"log"
 	"regexp"
 	"strings"
 
 	"net/http"
+	"time"
 
-	"github.com/trufflesecurity/trufflehog/pkg/common"
 	"github.com/trufflesecurity/trufflehog/pkg/detectors"
 	"github.com/trufflesecurity/trufflehog/pkg/pb/detectorspb"
 )
@@ -21,7 +20,20 @@ type Scanner struct{}
 var _ detectors.Detector = (*Scanner)(nil)
 
 var (
-	client = common.SaneHttpClient()
+	// The besttime key-lookup endpoint is slower to respond than most
+	// verification endpoints, so we allow one extra attempt and a
+	// longer backoff than the package default.
+	client = detectors.NewRetryingClient(
+		detectors.WithRetryPolicy(detectors.RetryPolicy{
+			MaxAttempts: 4,
+			BaseDelay:   time.Second,
+			MaxDelay:    10 * time.Second,
+			Jitter:      0.2,
+		}),
+		detectors.WithLogf(func(format string, args ...any) {
+			log.Printf("besttime verify: "+format, args...)
+		}),
+	)
 
 	//Make sure that your group is surrounded in boundry characters such as below to reduce false positives
 	keyPat = regexp.MustCompile(detectors.PrefixRegex([]string{"besttime"}) + `\b([0-9A-Za-z_]{36})\b`)
@@ -0,0 +1,192 @@
+package detectors
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/trufflesecurity/trufflehog/pkg/common"
+)
+
+// RetryPolicy controls how NewRetryingClient schedules verification retries:
+// up to MaxAttempts tries total, backing off exponentially from BaseDelay
+// (capped at MaxDelay) with Jitter applied to avoid synchronized retries
+// across concurrently verifying detectors.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64
+}
+
+// DefaultRetryPolicy is used by NewRetryingClient unless overridden with
+// WithRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+	Jitter:      0.2,
+}
+
+// RetryClientOption configures the client returned by NewRetryingClient.
+type RetryClientOption func(*retryingRoundTripper)
+
+// WithRetryPolicy overrides the default retry policy. Detectors whose
+// verification endpoint is known to be slower or flakier than most can use
+// this to allow more attempts or a longer backoff.
+func WithRetryPolicy(policy RetryPolicy) RetryClientOption {
+	return func(rt *retryingRoundTripper) { rt.policy = policy }
+}
+
+// WithRetryableStatusCodes marks additional HTTP status codes as retryable.
+// 429 and every 5xx are always retryable and don't need to be listed.
+func WithRetryableStatusCodes(codes ...int) RetryClientOption {
+	return func(rt *retryingRoundTripper) {
+		for _, code := range codes {
+			rt.extraRetryableCodes[code] = struct{}{}
+		}
+	}
+}
+
+// WithLogf attaches a structured per-attempt logger, e.g. log.Printf. If
+// unset, retries happen silently.
+func WithLogf(logf func(format string, args ...any)) RetryClientOption {
+	return func(rt *retryingRoundTripper) { rt.logf = logf }
+}
+
+// NewRetryingClient wraps common.SaneHttpClient() with a retrying
+// http.RoundTripper so that a transient network error, 429, or 5xx during
+// verification doesn't surface as a false-negative. Detectors adopt it by
+// changing:
+//
+//	client = common.SaneHttpClient()
+//
+// to:
+//
+//	client = detectors.NewRetryingClient()
+//
+// Anything else - a connection that succeeds with a 4xx, or a 200 whose body
+// doesn't match the provider's "valid key" pattern - is terminal and is
+// returned to the caller on the first attempt, same as before.
+func NewRetryingClient(opts ...RetryClientOption) *http.Client {
+	base := common.SaneHttpClient()
+	rt := &retryingRoundTripper{
+		next:                base.Transport,
+		policy:              DefaultRetryPolicy,
+		extraRetryableCodes: map[int]struct{}{},
+	}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	if rt.next == nil {
+		rt.next = http.DefaultTransport
+	}
+	base.Transport = rt
+	return base
+}
+
+// retryingRoundTripper is the http.RoundTripper installed by
+// NewRetryingClient. It buffers the request body (verification requests are
+// small, so this is cheap) so the same request can be replayed on retry.
+type retryingRoundTripper struct {
+	next                http.RoundTripper
+	policy              RetryPolicy
+	extraRetryableCodes map[int]struct{}
+	logf                func(format string, args ...any)
+}
+
+func (rt *retryingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var bodyBytes []byte
+	if req.Body != nil {
+		var err error
+		bodyBytes, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("detectors: buffering request body for retry: %w", err)
+		}
+	}
+
+	attempts := rt.policy.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultRetryPolicy.MaxAttempts
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if bodyBytes != nil {
+			req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		}
+
+		rt.log("verification attempt %d/%d: %s %s", attempt, attempts, req.Method, req.URL)
+
+		res, err := rt.next.RoundTrip(req)
+		if retryable, classifyErr := rt.classify(res, err); !retryable {
+			return res, err
+		} else {
+			lastErr = classifyErr
+		}
+		if res != nil {
+			res.Body.Close()
+		}
+		if attempt == attempts {
+			break
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(rt.backoff(attempt)):
+		}
+	}
+
+	return nil, fmt.Errorf("detectors: verification request failed after %d attempts: %w", attempts, lastErr)
+}
+
+// classify reports whether a round trip result is worth retrying: network
+// errors, 429, and 5xx are retryable; everything else - including a
+// successful response - is terminal.
+func (rt *retryingRoundTripper) classify(res *http.Response, err error) (retryable bool, classifyErr error) {
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) {
+			return true, err
+		}
+		return false, err
+	}
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500 {
+		return true, fmt.Errorf("status %d", res.StatusCode)
+	}
+	if _, ok := rt.extraRetryableCodes[res.StatusCode]; ok {
+		return true, fmt.Errorf("status %d", res.StatusCode)
+	}
+	return false, nil
+}
+
+// backoff computes the exponential, jittered delay before the retry
+// following the given attempt number.
+func (rt *retryingRoundTripper) backoff(attempt int) time.Duration {
+	delay := float64(rt.policy.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if rt.policy.MaxDelay > 0 && delay > float64(rt.policy.MaxDelay) {
+		delay = float64(rt.policy.MaxDelay)
+	}
+	if rt.policy.Jitter > 0 {
+		delay += delay * rt.policy.Jitter * (rand.Float64()*2 - 1)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return time.Duration(delay)
+}
+
+func (rt *retryingRoundTripper) log(format string, args ...any) {
+	if rt.logf == nil {
+		return
+	}
+	rt.logf(format, args...)
+}
@@ -0,0 +1,99 @@
+package detectors
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeNetErr is a minimal net.Error for exercising the network-error
+// retryable path without opening a real connection.
+type fakeNetErr struct{}
+
+func (fakeNetErr) Error() string   { return "fake network error" }
+func (fakeNetErr) Timeout() bool   { return true }
+func (fakeNetErr) Temporary() bool { return true }
+
+func TestRetryingRoundTripperClassify(t *testing.T) {
+	rt := &retryingRoundTripper{extraRetryableCodes: map[int]struct{}{}}
+	rt.extraRetryableCodes[http.StatusRequestTimeout] = struct{}{}
+
+	tests := []struct {
+		name          string
+		res           *http.Response
+		err           error
+		wantRetryable bool
+	}{
+		{name: "network error", err: fakeNetErr{}, wantRetryable: true},
+		{name: "non-network error", err: errors.New("boom"), wantRetryable: false},
+		{name: "429 too many requests", res: &http.Response{StatusCode: http.StatusTooManyRequests}, wantRetryable: true},
+		{name: "500 internal server error", res: &http.Response{StatusCode: http.StatusInternalServerError}, wantRetryable: true},
+		{name: "503 service unavailable", res: &http.Response{StatusCode: http.StatusServiceUnavailable}, wantRetryable: true},
+		{name: "configured extra retryable code", res: &http.Response{StatusCode: http.StatusRequestTimeout}, wantRetryable: true},
+		{name: "404 not found is terminal", res: &http.Response{StatusCode: http.StatusNotFound}, wantRetryable: false},
+		{name: "401 unauthorized is terminal", res: &http.Response{StatusCode: http.StatusUnauthorized}, wantRetryable: false},
+		{name: "200 success is terminal", res: &http.Response{StatusCode: http.StatusOK}, wantRetryable: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retryable, _ := rt.classify(tt.res, tt.err)
+			if retryable != tt.wantRetryable {
+				t.Errorf("classify() retryable = %v, want %v", retryable, tt.wantRetryable)
+			}
+		})
+	}
+}
+
+func TestRetryingRoundTripperBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		// wantBase is the delay after clamping to MaxDelay, before jitter
+		// is applied.
+		wantBase time.Duration
+	}{
+		{
+			name:     "first attempt uses base delay",
+			policy:   RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: 0.2},
+			attempt:  1,
+			wantBase: 100 * time.Millisecond,
+		},
+		{
+			name:     "doubles each attempt",
+			policy:   RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: 0.2},
+			attempt:  3,
+			wantBase: 400 * time.Millisecond,
+		},
+		{
+			name:     "clamps to max delay",
+			policy:   RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: 0.2},
+			attempt:  10,
+			wantBase: time.Second,
+		},
+		{
+			name:     "no jitter is exact",
+			policy:   RetryPolicy{BaseDelay: 50 * time.Millisecond, MaxDelay: time.Second, Jitter: 0},
+			attempt:  2,
+			wantBase: 100 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := &retryingRoundTripper{policy: tt.policy}
+			lower := float64(tt.wantBase) * (1 - tt.policy.Jitter)
+			upper := float64(tt.wantBase) * (1 + tt.policy.Jitter)
+
+			// Sample several times since jitter is randomized.
+			for i := 0; i < 20; i++ {
+				got := rt.backoff(tt.attempt)
+				if float64(got) < lower || float64(got) > upper {
+					t.Fatalf("backoff(%d) = %v, want in [%v, %v]", tt.attempt, got, time.Duration(lower), time.Duration(upper))
+				}
+			}
+		})
+	}
+}
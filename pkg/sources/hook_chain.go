@@ -0,0 +1,231 @@
+package sources
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/trufflesecurity/trufflehog/v3/pkg/context"
+)
+
+// HookHandler pairs a JobProgressHook with a predicate controlling which
+// calls it receives. A nil ShouldHandle always handles.
+type HookHandler struct {
+	Hook         JobProgressHook
+	ShouldHandle func(ref JobProgressRef, unit SourceUnit) bool
+}
+
+func (h HookHandler) shouldHandle(ref JobProgressRef, unit SourceUnit) bool {
+	if h.ShouldHandle == nil {
+		return true
+	}
+	return h.ShouldHandle(ref, unit)
+}
+
+// HookChain multiplexes JobProgressHook calls to an ordered list of
+// registered handlers, each gated by its own predicate. This replaces
+// passing a single hook (or embedding NoopHook and overriding piecemeal)
+// when a caller wants several independent hooks active at once - for
+// example a UnitHook tracking metrics alongside a SlowUnitHook that only
+// cares about units over a duration threshold.
+//
+// Handlers are invoked in registration order for every call, including
+// Start*/End* pairs, so a handler that correlates a unit's start and end
+// can rely on seeing both. ReportError is only forwarded to handlers whose
+// predicate matches the unit (if any) the error is attributed to.
+type HookChain struct {
+	handlers []HookHandler
+}
+
+// Ensure HookChain satisfies the interface at compile time.
+var _ JobProgressHook = (*HookChain)(nil)
+
+// NewHookChain creates a HookChain from an initial set of handlers.
+// Additional handlers can be registered later with Register.
+func NewHookChain(handlers ...HookHandler) *HookChain {
+	return &HookChain{handlers: handlers}
+}
+
+// Register appends a handler to the chain. Handlers are invoked in
+// registration order.
+func (c *HookChain) Register(hook JobProgressHook, shouldHandle func(JobProgressRef, SourceUnit) bool) {
+	c.handlers = append(c.handlers, HookHandler{Hook: hook, ShouldHandle: shouldHandle})
+}
+
+func (c *HookChain) Start(ref JobProgressRef, start time.Time) {
+	for _, h := range c.handlers {
+		if h.shouldHandle(ref, nil) {
+			h.Hook.Start(ref, start)
+		}
+	}
+}
+
+func (c *HookChain) End(ref JobProgressRef, end time.Time) {
+	for _, h := range c.handlers {
+		if h.shouldHandle(ref, nil) {
+			h.Hook.End(ref, end)
+		}
+	}
+}
+
+func (c *HookChain) StartEnumerating(ref JobProgressRef, start time.Time) {
+	for _, h := range c.handlers {
+		if h.shouldHandle(ref, nil) {
+			h.Hook.StartEnumerating(ref, start)
+		}
+	}
+}
+
+func (c *HookChain) EndEnumerating(ref JobProgressRef, end time.Time) {
+	for _, h := range c.handlers {
+		if h.shouldHandle(ref, nil) {
+			h.Hook.EndEnumerating(ref, end)
+		}
+	}
+}
+
+func (c *HookChain) StartUnitChunking(ref JobProgressRef, unit SourceUnit, start time.Time) {
+	for _, h := range c.handlers {
+		if h.shouldHandle(ref, unit) {
+			h.Hook.StartUnitChunking(ref, unit, start)
+		}
+	}
+}
+
+func (c *HookChain) EndUnitChunking(ref JobProgressRef, unit SourceUnit, end time.Time) {
+	for _, h := range c.handlers {
+		if h.shouldHandle(ref, unit) {
+			h.Hook.EndUnitChunking(ref, unit, end)
+		}
+	}
+}
+
+// ReportError forwards err to every handler whose predicate matches the
+// unit it's attributed to (nil if err isn't a ChunkError for a specific
+// unit). A handler whose predicate misses never sees the error.
+func (c *HookChain) ReportError(ref JobProgressRef, err error) {
+	var unit SourceUnit
+	var chunkErr ChunkError
+	if errors.As(err, &chunkErr) {
+		unit = chunkErr.Unit
+	}
+	for _, h := range c.handlers {
+		if h.shouldHandle(ref, unit) {
+			h.Hook.ReportError(ref, err)
+		}
+	}
+}
+
+func (c *HookChain) ReportUnit(ref JobProgressRef, unit SourceUnit) {
+	for _, h := range c.handlers {
+		if h.shouldHandle(ref, unit) {
+			h.Hook.ReportUnit(ref, unit)
+		}
+	}
+}
+
+func (c *HookChain) ReportChunk(ref JobProgressRef, unit SourceUnit, chunk *Chunk) {
+	for _, h := range c.handlers {
+		if h.shouldHandle(ref, unit) {
+			h.Hook.ReportChunk(ref, unit, chunk)
+		}
+	}
+}
+
+func (c *HookChain) Finish(ref JobProgressRef) {
+	for _, h := range c.handlers {
+		if h.shouldHandle(ref, nil) {
+			h.Hook.Finish(ref)
+		}
+	}
+}
+
+// SlowUnitHook logs units whose chunking time exceeds Threshold. It's
+// meant to be registered in a HookChain alongside a UnitHook, so the two
+// can share a job without either one absorbing the other's concerns.
+type SlowUnitHook struct {
+	// Threshold is the chunking duration above which a unit is logged.
+	Threshold time.Duration
+
+	ctx context.Context
+
+	mu     sync.Mutex
+	starts map[string]time.Time
+	NoopHook
+}
+
+// NewSlowUnitHook creates a SlowUnitHook that logs units taking longer than
+// threshold to chunk.
+func NewSlowUnitHook(ctx context.Context, threshold time.Duration) *SlowUnitHook {
+	return &SlowUnitHook{
+		ctx:       ctx,
+		Threshold: threshold,
+		starts:    make(map[string]time.Time),
+	}
+}
+
+func (s *SlowUnitHook) StartUnitChunking(ref JobProgressRef, unit SourceUnit, start time.Time) {
+	id := jobUnitID(ref, unit)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.starts[id] = start
+}
+
+func (s *SlowUnitHook) EndUnitChunking(ref JobProgressRef, unit SourceUnit, end time.Time) {
+	id := jobUnitID(ref, unit)
+	s.mu.Lock()
+	start, ok := s.starts[id]
+	delete(s.starts, id)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if elapsed := end.Sub(start); elapsed > s.Threshold {
+		unitID := ""
+		if unit != nil {
+			unitID = unit.SourceUnitID()
+		}
+		s.ctx.Logger().Info("slow unit",
+			"id", unitID,
+			"sourceID", ref.SourceID,
+			"jobID", ref.JobID,
+			"elapsed", elapsed,
+			"threshold", s.Threshold,
+		)
+	}
+}
+
+// ChunkSamplingHook forwards one in every K ReportChunk calls to Downstream,
+// for cheap debug tracing of chunk content without paying the cost of
+// forwarding every chunk.
+type ChunkSamplingHook struct {
+	Downstream JobProgressHook
+	// Every is the sampling rate: one out of every Every chunks is
+	// forwarded.
+	Every int
+
+	mu    sync.Mutex
+	count uint64
+	NoopHook
+}
+
+// NewChunkSamplingHook creates a ChunkSamplingHook that forwards one in
+// every `every` chunks to downstream.
+func NewChunkSamplingHook(downstream JobProgressHook, every int) *ChunkSamplingHook {
+	if every < 1 {
+		every = 1
+	}
+	return &ChunkSamplingHook{Downstream: downstream, Every: every}
+}
+
+func (c *ChunkSamplingHook) ReportChunk(ref JobProgressRef, unit SourceUnit, chunk *Chunk) {
+	c.mu.Lock()
+	c.count++
+	sample := c.count%uint64(c.Every) == 0
+	c.mu.Unlock()
+
+	if sample {
+		c.Downstream.ReportChunk(ref, unit, chunk)
+	}
+}
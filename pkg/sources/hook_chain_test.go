@@ -0,0 +1,200 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/trufflesecurity/trufflehog/v3/pkg/context"
+)
+
+// callRecorder is a JobProgressHook that records which of its methods were
+// called and with which unit, so chain tests can assert exactly who was
+// forwarded a call.
+type callRecorder struct {
+	mu    sync.Mutex
+	calls []string
+	NoopHook
+}
+
+func (r *callRecorder) record(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, name)
+}
+
+func (r *callRecorder) Start(JobProgressRef, time.Time) { r.record("start") }
+
+func (r *callRecorder) ReportChunk(_ JobProgressRef, unit SourceUnit, _ *Chunk) {
+	r.record(fmt.Sprintf("chunk:%s", unit.SourceUnitID()))
+}
+
+func (r *callRecorder) ReportError(JobProgressRef, error) { r.record("error") }
+
+func (r *callRecorder) calledWith(want string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.calls {
+		if c == want {
+			return true
+		}
+	}
+	return false
+}
+
+func onlyUnit(id string) func(JobProgressRef, SourceUnit) bool {
+	return func(_ JobProgressRef, unit SourceUnit) bool {
+		return unit != nil && unit.SourceUnitID() == id
+	}
+}
+
+func TestHookChainForwardsOnlyMatchingHandlers(t *testing.T) {
+	matching := &callRecorder{}
+	catchAll := &callRecorder{}
+
+	chain := NewHookChain(
+		HookHandler{Hook: matching, ShouldHandle: onlyUnit(indexedUnit(1).SourceUnitID())},
+		HookHandler{Hook: catchAll},
+	)
+
+	ref := JobProgressRef{}
+	chain.ReportChunk(ref, indexedUnit(1), &Chunk{})
+	chain.ReportChunk(ref, indexedUnit(2), &Chunk{})
+
+	if !matching.calledWith("chunk:unit-1") {
+		t.Error("handler predicated on unit-1 was not called for unit-1")
+	}
+	if matching.calledWith("chunk:unit-2") {
+		t.Error("handler predicated on unit-1 was called for unit-2, predicate should have short-circuited it")
+	}
+	if !catchAll.calledWith("chunk:unit-1") || !catchAll.calledWith("chunk:unit-2") {
+		t.Error("handler with no predicate should have been called for every unit")
+	}
+}
+
+func TestHookChainReportErrorAttribution(t *testing.T) {
+	unitSpecific := &callRecorder{}
+	catchAll := &callRecorder{}
+
+	chain := NewHookChain(
+		HookHandler{Hook: unitSpecific, ShouldHandle: onlyUnit(indexedUnit(5).SourceUnitID())},
+		HookHandler{Hook: catchAll},
+	)
+
+	ref := JobProgressRef{}
+
+	// An error attributed to unit-5 via ChunkError should reach both.
+	chain.ReportError(ref, ChunkError{Unit: indexedUnit(5), Err: errors.New("boom")})
+	if !unitSpecific.calledWith("error") {
+		t.Error("handler predicated on unit-5 should have received a ChunkError for unit-5")
+	}
+	if !catchAll.calledWith("error") {
+		t.Error("catch-all handler should always receive ReportError")
+	}
+
+	// A plain (non-ChunkError) error carries no unit, so the unit-5
+	// predicate should miss it.
+	unitSpecific2 := &callRecorder{}
+	chain2 := NewHookChain(
+		HookHandler{Hook: unitSpecific2, ShouldHandle: onlyUnit(indexedUnit(5).SourceUnitID())},
+	)
+	chain2.ReportError(ref, errors.New("no unit here"))
+	if unitSpecific2.calledWith("error") {
+		t.Error("handler predicated on a specific unit should not receive an error with no unit attribution")
+	}
+}
+
+func TestHookChainPreservesRegistrationOrder(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+
+	newOrdered := func(name string) JobProgressHook {
+		h := &callRecorder{}
+		return hookFunc{
+			start: func(ref JobProgressRef, start time.Time) {
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+				h.Start(ref, start)
+			},
+		}
+	}
+
+	chain := NewHookChain(
+		HookHandler{Hook: newOrdered("first")},
+		HookHandler{Hook: newOrdered("second")},
+		HookHandler{Hook: newOrdered("third")},
+	)
+	chain.Start(JobProgressRef{}, time.Now())
+
+	want := []string{"first", "second", "third"}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != len(want) {
+		t.Fatalf("got %d calls, want %d", len(order), len(want))
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Errorf("call order[%d] = %s, want %s", i, order[i], want[i])
+		}
+	}
+}
+
+// hookFunc adapts a Start callback into a JobProgressHook for ordering
+// tests, without needing a dedicated named type per test.
+type hookFunc struct {
+	start func(JobProgressRef, time.Time)
+	NoopHook
+}
+
+func (h hookFunc) Start(ref JobProgressRef, start time.Time) { h.start(ref, start) }
+
+func TestChunkSamplingHookForwardsOneInEvery(t *testing.T) {
+	downstream := &callRecorder{}
+	hook := NewChunkSamplingHook(downstream, 3)
+
+	ref := JobProgressRef{}
+	const total = 9
+	for i := 0; i < total; i++ {
+		hook.ReportChunk(ref, indexedUnit(i), &Chunk{})
+	}
+
+	downstream.mu.Lock()
+	got := len(downstream.calls)
+	downstream.mu.Unlock()
+
+	want := total / 3
+	if got != want {
+		t.Errorf("downstream received %d chunks, want %d (1 in every 3 of %d)", got, want, total)
+	}
+}
+
+func TestSlowUnitHookCleansUpStartState(t *testing.T) {
+	ctx := context.Background()
+	hook := NewSlowUnitHook(ctx, 10*time.Millisecond)
+	ref := JobProgressRef{}
+	unit := indexedUnit(1)
+
+	start := time.Now()
+	hook.StartUnitChunking(ref, unit, start)
+	hook.EndUnitChunking(ref, unit, start.Add(time.Millisecond)) // below threshold
+
+	hook.mu.Lock()
+	remaining := len(hook.starts)
+	hook.mu.Unlock()
+	if remaining != 0 {
+		t.Errorf("starts map has %d entries after EndUnitChunking, want 0", remaining)
+	}
+
+	hook.StartUnitChunking(ref, unit, start)
+	hook.EndUnitChunking(ref, unit, start.Add(20*time.Millisecond)) // above threshold
+
+	hook.mu.Lock()
+	remaining = len(hook.starts)
+	hook.mu.Unlock()
+	if remaining != 0 {
+		t.Errorf("starts map has %d entries after a slow unit finishes, want 0", remaining)
+	}
+}
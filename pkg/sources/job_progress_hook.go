@@ -8,48 +8,99 @@ import (
 	"time"
 
 	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/trufflesecurity/trufflehog/v3/pkg/context"
 )
 
 // UnitHook implements JobProgressHook for tracking the progress of each
 // individual unit.
 type UnitHook struct {
+	// active holds metrics for units that have been started but not yet
+	// finished, keyed by id. It's deliberately not bounded: a unit only
+	// moves into the size-capped metrics cache - and becomes eligible for
+	// LRU eviction - once EndUnitChunking reports it's done, so a worker
+	// pool with many units in flight at once can never have an in-progress
+	// unit's metrics evicted out from under it.
+	active  map[string]*UnitMetrics
 	metrics *lru.Cache[string, *UnitMetrics]
 	mu      sync.Mutex
+
+	// evictions counts metrics dropped from the cache before being read, if
+	// WithPrometheusRegisterer was used to set it up.
+	evictions prometheus.Counter
+
 	NoopHook
 }
 
+// jobUnitID formats the key JobProgressHook implementations that need to
+// correlate a unit's Start/Report/End calls (UnitHook, SlowUnitHook) use to
+// identify it.
+func jobUnitID(ref JobProgressRef, unit SourceUnit) string {
+	unitID := ""
+	if unit != nil {
+		unitID = unit.SourceUnitID()
+	}
+	return fmt.Sprintf("%d/%d/%s", ref.SourceID, ref.JobID, unitID)
+}
+
 type UnitHookOpt func(*UnitHook)
 
 func WithUnitHookCache(cache *lru.Cache[string, *UnitMetrics]) UnitHookOpt {
 	return func(hook *UnitHook) { hook.metrics = cache }
 }
 
+// WithPrometheusRegisterer registers a trufflehog_unit_evictions_total
+// counter on reg and wires NewUnitHook's default eviction callback to
+// increment it whenever a metric is dropped from the cache before being
+// read - the silent data-loss case that otherwise only logs. Pair this with
+// a PrometheusExporter to publish the rest of UnitHook's metrics.
+func WithPrometheusRegisterer(reg prometheus.Registerer) UnitHookOpt {
+	return func(hook *UnitHook) {
+		counter := prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "trufflehog_unit_evictions_total",
+			Help: "Total number of unit metrics dropped from the UnitHook cache before being read.",
+		})
+		reg.MustRegister(counter)
+		hook.evictions = counter
+	}
+}
+
 func NewUnitHook(ctx context.Context, opts ...UnitHookOpt) *UnitHook {
-	// lru.NewWithEvict can only fail if the size is < 0.
-	cache, _ := lru.NewWithEvict(1024, func(key string, value *UnitMetrics) {
-		if value.handled {
-			return
-		}
-		ctx.Logger().Error(fmt.Errorf("eviction"), "dropping unit metric",
-			"id", key,
-			"metric", value,
-		)
-	})
-	hook := UnitHook{metrics: cache}
+	hook := &UnitHook{active: make(map[string]*UnitMetrics)}
 	for _, opt := range opts {
-		opt(&hook)
+		opt(hook)
+	}
+	if hook.metrics == nil {
+		// lru.NewWithEvict can only fail if the size is < 0.
+		//
+		// Apart from the shared nil-unit entry ReportChunk creates for
+		// sources without unit support, only finished units land in this
+		// cache, so eviction here means "finished, but nobody called
+		// UnitMetrics() in time to read it" - not "evicted out from under
+		// an in-progress worker", which active exists to rule out. The
+		// callback runs synchronously inside whichever UnitHook method
+		// triggered it (Add/Remove), all called with mu held, so it's safe
+		// under concurrent ParallelChunker workers.
+		cache, _ := lru.NewWithEvict(1024, func(key string, value *UnitMetrics) {
+			if value.handled {
+				return
+			}
+			if hook.evictions != nil {
+				hook.evictions.Inc()
+			}
+			ctx.Logger().Error(fmt.Errorf("eviction"), "dropping unit metric",
+				"id", key,
+				"metric", value,
+			)
+		})
+		hook.metrics = cache
 	}
-	return &hook
+	return hook
 }
 
 // id is a helper method to generate an ID for the given job and unit.
 func (u *UnitHook) id(ref JobProgressRef, unit SourceUnit) string {
-	unitID := ""
-	if unit != nil {
-		unitID = unit.SourceUnitID()
-	}
-	return fmt.Sprintf("%d/%d/%s", ref.SourceID, ref.JobID, unitID)
+	return jobUnitID(ref, unit)
 }
 
 func (u *UnitHook) StartUnitChunking(ref JobProgressRef, unit SourceUnit, start time.Time) {
@@ -57,11 +108,11 @@ func (u *UnitHook) StartUnitChunking(ref JobProgressRef, unit SourceUnit, start
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
-	u.metrics.Add(id, &UnitMetrics{
+	u.active[id] = &UnitMetrics{
 		Unit:      unit,
 		Parent:    ref,
 		StartTime: &start,
-	})
+	}
 }
 
 func (u *UnitHook) EndUnitChunking(ref JobProgressRef, unit SourceUnit, end time.Time) {
@@ -69,11 +120,13 @@ func (u *UnitHook) EndUnitChunking(ref JobProgressRef, unit SourceUnit, end time
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
-	metrics, ok := u.metrics.Get(id)
+	metrics, ok := u.active[id]
 	if !ok {
 		return
 	}
 	metrics.EndTime = &end
+	delete(u.active, id)
+	u.metrics.Add(id, metrics)
 }
 
 func (u *UnitHook) ReportChunk(ref JobProgressRef, unit SourceUnit, chunk *Chunk) {
@@ -81,9 +134,12 @@ func (u *UnitHook) ReportChunk(ref JobProgressRef, unit SourceUnit, chunk *Chunk
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
-	metrics, ok := u.metrics.Get(id)
+	metrics, ok := u.active[id]
+	if !ok {
+		metrics, ok = u.metrics.Get(id)
+	}
 	if !ok && unit != nil {
-		// The unit has been evicted.
+		// The unit was never started, or finished and was since evicted.
 		return
 	} else if !ok && unit == nil {
 		// This is a chunk from a non-unit source.
@@ -103,7 +159,7 @@ func (u *UnitHook) ReportError(ref JobProgressRef, err error) {
 	defer u.mu.Unlock()
 
 	// Always add the error to the nil unit if it exists.
-	if metrics, ok := u.metrics.Get(u.id(ref, nil)); ok {
+	if metrics, ok := u.activeOrCached(u.id(ref, nil)); ok {
 		metrics.Errors = append(metrics.Errors, err)
 	}
 
@@ -114,19 +170,45 @@ func (u *UnitHook) ReportError(ref JobProgressRef, err error) {
 	}
 	id := u.id(ref, chunkErr.Unit)
 
-	metrics, ok := u.metrics.Get(id)
+	metrics, ok := u.activeOrCached(id)
 	if !ok {
 		return
 	}
 	metrics.Errors = append(metrics.Errors, err)
 }
 
+// activeOrCached looks up id among units still being chunked before
+// checking the bounded cache of finished-but-unread ones. Callers must hold
+// u.mu.
+func (u *UnitHook) activeOrCached(id string) (*UnitMetrics, bool) {
+	if metrics, ok := u.active[id]; ok {
+		return metrics, true
+	}
+	return u.metrics.Get(id)
+}
+
 func (u *UnitHook) Finish(ref JobProgressRef) {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 	// Clear out any metrics on this job. This covers the case for the
 	// source running without unit support.
 	prefix := u.id(ref, nil)
+	finishNoUnitSource := func(metric *UnitMetrics) {
+		// If the unit is nil, the source does not support units.
+		// Use the overall job metrics instead.
+		if metric.Unit != nil {
+			return
+		}
+		snap := ref.Snapshot()
+		metric.StartTime = snap.StartTime
+		metric.EndTime = snap.EndTime
+		metric.Errors = snap.Errors
+	}
+	for id, metric := range u.active {
+		if strings.HasPrefix(id, prefix) {
+			finishNoUnitSource(metric)
+		}
+	}
 	for _, id := range u.metrics.Keys() {
 		if !strings.HasPrefix(id, prefix) {
 			continue
@@ -135,14 +217,7 @@ func (u *UnitHook) Finish(ref JobProgressRef) {
 		if !ok {
 			continue
 		}
-		// If the unit is nil, the source does not support units.
-		// Use the overall job metrics instead.
-		if metric.Unit == nil {
-			snap := ref.Snapshot()
-			metric.StartTime = snap.StartTime
-			metric.EndTime = snap.EndTime
-			metric.Errors = snap.Errors
-		}
+		finishNoUnitSource(metric)
 	}
 }
 
@@ -152,7 +227,10 @@ func (u *UnitHook) Finish(ref JobProgressRef) {
 func (u *UnitHook) UnitMetrics() []UnitMetrics {
 	u.mu.Lock()
 	defer u.mu.Unlock()
-	output := make([]UnitMetrics, 0, u.metrics.Len())
+	output := make([]UnitMetrics, 0, len(u.active)+u.metrics.Len())
+	for _, metric := range u.active {
+		output = append(output, *metric)
+	}
 	for _, id := range u.metrics.Keys() {
 		metric, ok := u.metrics.Get(id)
 		if !ok {
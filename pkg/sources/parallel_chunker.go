@@ -0,0 +1,179 @@
+package sources
+
+import (
+	"sync"
+	"time"
+
+	"github.com/trufflesecurity/trufflehog/v3/pkg/context"
+)
+
+const (
+	// defaultChunkerThreshold is how many units may sit in the queue before
+	// ParallelChunker starts spinning up extra workers beyond the base one.
+	defaultChunkerThreshold = 64
+	// defaultChunkerConcurrency is the most workers ParallelChunker will
+	// ever run concurrently.
+	defaultChunkerConcurrency = 8
+)
+
+// UnitChunkFunc chunks a single SourceUnit, forwarding each Chunk it
+// produces to report. It supplies the actual chunking work (e.g. reading a
+// git blob, paginating an API) and is provided by the Source being wrapped.
+type UnitChunkFunc func(ctx context.Context, unit SourceUnit, report func(*Chunk)) error
+
+// ParallelChunkerOpt configures a ParallelChunker returned by
+// NewParallelChunker.
+type ParallelChunkerOpt func(*ParallelChunker)
+
+// WithChunkerConcurrency sets the most workers ParallelChunker will run at
+// once. Default 8.
+func WithChunkerConcurrency(n int) ParallelChunkerOpt {
+	return func(p *ParallelChunker) { p.concurrency = n }
+}
+
+// WithChunkerThreshold sets how many units may be queued awaiting chunking
+// before ParallelChunker starts spinning up workers beyond the base one.
+// Default 64.
+func WithChunkerThreshold(n int) ParallelChunkerOpt {
+	return func(p *ParallelChunker) { p.threshold = n }
+}
+
+// ParallelChunker sits between a Source's unit enumeration and its
+// JobProgressHook chain. Sources chunk one unit at a time by default; for
+// scans that enumerate many small units (e.g. a git repo with thousands of
+// small blobs) that serializes work that's actually independent per unit.
+//
+// ParallelChunker streams enumerated units onto a queue that a single base
+// worker drains as they arrive - so chunking starts immediately rather than
+// waiting for enumeration to finish - and only once the queue backs up past
+// a threshold does it spin up additional workers (up to a configurable
+// concurrency) to help drain it, the same shape as a trie committer that
+// waits until its working set is large enough to be worth the goroutine
+// overhead before parallelizing. A scan small enough to never cross the
+// threshold never pays for more than that one worker.
+type ParallelChunker struct {
+	ctx   context.Context
+	ref   JobProgressRef
+	hook  JobProgressHook
+	chunk UnitChunkFunc
+
+	threshold   int
+	concurrency int
+
+	queue chan SourceUnit
+
+	mu     sync.Mutex
+	active int
+	wg     sync.WaitGroup
+
+	errMu    sync.Mutex
+	firstErr error
+
+	closeOnce sync.Once
+}
+
+// NewParallelChunker creates a ParallelChunker that reports progress for ref
+// through hook, chunking each enumerated unit with chunkFn. It starts its
+// base worker immediately; callers feed it units with Enumerate and collect
+// the result with Wait.
+func NewParallelChunker(ctx context.Context, ref JobProgressRef, hook JobProgressHook, chunkFn UnitChunkFunc, opts ...ParallelChunkerOpt) *ParallelChunker {
+	p := &ParallelChunker{
+		ctx:         ctx,
+		ref:         ref,
+		hook:        hook,
+		chunk:       chunkFn,
+		threshold:   defaultChunkerThreshold,
+		concurrency: defaultChunkerConcurrency,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.concurrency < 1 {
+		p.concurrency = 1
+	}
+	// Buffered enough that a burst of Enumerate calls can run ahead of the
+	// base worker without blocking while the scaler notices and spins up
+	// help; Enumerate still blocks (providing backpressure) if the backlog
+	// grows past that.
+	p.queue = make(chan SourceUnit, p.threshold*4)
+
+	p.active = 1
+	p.wg.Add(1)
+	go p.work()
+
+	return p
+}
+
+// Enumerate queues a unit discovered during enumeration for chunking. It
+// may be called concurrently with chunking already in progress; workers
+// drain the queue as units arrive rather than waiting for enumeration to
+// finish. Call Wait once enumeration is complete to collect the result.
+func (p *ParallelChunker) Enumerate(unit SourceUnit) {
+	p.queue <- unit
+	p.maybeScaleUp()
+}
+
+// maybeScaleUp spins up another worker if the queue has backed up past the
+// threshold and we're still under the concurrency cap. Workers that turn
+// out not to be needed simply block on the now-quiet queue, which costs a
+// goroutine but no CPU.
+func (p *ParallelChunker) maybeScaleUp() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.queue) <= p.threshold || p.active >= p.concurrency {
+		return
+	}
+	p.active++
+	p.wg.Add(1)
+	go p.work()
+}
+
+func (p *ParallelChunker) work() {
+	defer p.wg.Done()
+	for unit := range p.queue {
+		if err := p.chunkOne(p.ctx, unit); err != nil {
+			p.recordErr(err)
+		}
+	}
+}
+
+func (p *ParallelChunker) recordErr(err error) {
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+	if p.firstErr == nil {
+		p.firstErr = err
+	}
+}
+
+// Wait closes the queue to further Enumerate calls, waits for every worker
+// to drain it, and returns the first error encountered, if any. Every
+// queued unit is attempted regardless of earlier failures.
+func (p *ParallelChunker) Wait() error {
+	p.closeOnce.Do(func() { close(p.queue) })
+	p.wg.Wait()
+
+	p.errMu.Lock()
+	defer p.errMu.Unlock()
+	return p.firstErr
+}
+
+// chunkOne drives the Start/Report/End hook triplet for a single unit
+// around the caller-supplied chunk function. Workers only ever operate on
+// distinct units, so the only shared state touched concurrently is the hook
+// chain itself - UnitHook already serializes its cache access under its own
+// mutex, so no additional locking is needed here.
+func (p *ParallelChunker) chunkOne(ctx context.Context, unit SourceUnit) error {
+	start := time.Now()
+	p.hook.StartUnitChunking(p.ref, unit, start)
+	defer func() {
+		p.hook.EndUnitChunking(p.ref, unit, time.Now())
+	}()
+
+	err := p.chunk(ctx, unit, func(chunk *Chunk) {
+		p.hook.ReportChunk(p.ref, unit, chunk)
+	})
+	if err != nil {
+		p.hook.ReportError(p.ref, ChunkError{Unit: unit, Err: err})
+	}
+	return err
+}
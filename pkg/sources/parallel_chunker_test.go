@@ -0,0 +1,257 @@
+package sources
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/trufflesecurity/trufflehog/v3/pkg/context"
+)
+
+// indexedUnit is a SourceUnit carrying its enumeration index, so test
+// chunkFns can make a deterministic subset of units fail.
+type indexedUnit int
+
+func (u indexedUnit) SourceUnitID() string { return fmt.Sprintf("unit-%d", int(u)) }
+
+// countingHook records, per unit id, how many times each Start/Report/End
+// call fired, plus every error reported - so a test can assert each unit
+// was driven through the hook triplet exactly once even when chunked by a
+// pool of concurrent workers.
+type countingHook struct {
+	mu     sync.Mutex
+	starts map[string]int
+	chunks map[string]int
+	ends   map[string]int
+	errs   []error
+	NoopHook
+}
+
+func newCountingHook() *countingHook {
+	return &countingHook{
+		starts: make(map[string]int),
+		chunks: make(map[string]int),
+		ends:   make(map[string]int),
+	}
+}
+
+func (h *countingHook) StartUnitChunking(_ JobProgressRef, unit SourceUnit, _ time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.starts[unit.SourceUnitID()]++
+}
+
+func (h *countingHook) ReportChunk(_ JobProgressRef, unit SourceUnit, _ *Chunk) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.chunks[unit.SourceUnitID()]++
+}
+
+func (h *countingHook) EndUnitChunking(_ JobProgressRef, unit SourceUnit, _ time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ends[unit.SourceUnitID()]++
+}
+
+func (h *countingHook) ReportError(_ JobProgressRef, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errs = append(h.errs, err)
+}
+
+// TestParallelChunkerConcurrentEnumerate enumerates many units from several
+// goroutines at once, forcing ParallelChunker past its scale-up threshold,
+// and asserts every unit is still driven through Start/Report/End exactly
+// once and that errors from the units that fail all surface through Wait.
+// Run with -race; this is the correctness test for the "chunked exactly
+// once" claim. Metric eviction is covered by
+// TestParallelChunkerNoMetricLostUnderSmallCache.
+func TestParallelChunkerConcurrentEnumerate(t *testing.T) {
+	const (
+		numUnits     = 500
+		numProducers = 10
+		failEvery    = 37
+	)
+
+	hook := newCountingHook()
+	ctx := context.Background()
+	chunkFn := func(_ context.Context, unit SourceUnit, report func(*Chunk)) error {
+		idx := int(unit.(indexedUnit))
+		report(&Chunk{Data: []byte(unit.SourceUnitID())})
+		if idx%failEvery == 0 {
+			return fmt.Errorf("synthetic failure for unit %d", idx)
+		}
+		return nil
+	}
+
+	p := NewParallelChunker(ctx, JobProgressRef{}, hook, chunkFn,
+		WithChunkerThreshold(8), WithChunkerConcurrency(4))
+
+	var wg sync.WaitGroup
+	wg.Add(numProducers)
+	for producer := 0; producer < numProducers; producer++ {
+		producer := producer
+		go func() {
+			defer wg.Done()
+			for i := producer; i < numUnits; i += numProducers {
+				p.Enumerate(indexedUnit(i))
+			}
+		}()
+	}
+	wg.Wait()
+
+	err := p.Wait()
+	if err == nil {
+		t.Fatal("Wait() returned nil error, want one of the synthetic per-unit failures")
+	}
+
+	wantFails := 0
+	for i := 0; i < numUnits; i++ {
+		if i%failEvery == 0 {
+			wantFails++
+		}
+	}
+	if len(hook.errs) != wantFails {
+		t.Errorf("got %d reported errors, want %d", len(hook.errs), wantFails)
+	}
+
+	for i := 0; i < numUnits; i++ {
+		id := indexedUnit(i).SourceUnitID()
+		if got := hook.starts[id]; got != 1 {
+			t.Errorf("unit %s: StartUnitChunking called %d times, want 1", id, got)
+		}
+		if got := hook.chunks[id]; got != 1 {
+			t.Errorf("unit %s: ReportChunk called %d times, want 1", id, got)
+		}
+		if got := hook.ends[id]; got != 1 {
+			t.Errorf("unit %s: EndUnitChunking called %d times, want 1", id, got)
+		}
+	}
+}
+
+// TestParallelChunkerNoMetricLostUnderSmallCache exercises chunk0-2's
+// central safety claim end to end: with several units in flight at once
+// against a UnitHook whose finished-metrics cache is far smaller than the
+// total number of units chunked, no unit's metrics are ever dropped before
+// being read, as long as UnitMetrics is polled (as a real caller would)
+// rather than read once at the very end.
+func TestParallelChunkerNoMetricLostUnderSmallCache(t *testing.T) {
+	const numUnits = 300
+
+	smallCache, err := lru.New[string, *UnitMetrics](8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	hook := NewUnitHook(ctx, WithUnitHookCache(smallCache))
+
+	chunkFn := func(_ context.Context, unit SourceUnit, report func(*Chunk)) error {
+		report(&Chunk{Data: []byte(unit.SourceUnitID())})
+		return nil
+	}
+	p := NewParallelChunker(ctx, JobProgressRef{SourceID: 1, JobID: 1}, hook, chunkFn,
+		WithChunkerThreshold(4), WithChunkerConcurrency(8))
+
+	seen := make(map[string]bool)
+	var seenMu sync.Mutex
+	collect := func() {
+		for _, m := range hook.UnitMetrics() {
+			if m.Unit == nil {
+				continue
+			}
+			seenMu.Lock()
+			seen[m.Unit.SourceUnitID()] = true
+			seenMu.Unlock()
+		}
+	}
+
+	done := make(chan struct{})
+	var pollWg sync.WaitGroup
+	pollWg.Add(1)
+	go func() {
+		defer pollWg.Done()
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				collect()
+				return
+			case <-ticker.C:
+				collect()
+			}
+		}
+	}()
+
+	for i := 0; i < numUnits; i++ {
+		p.Enumerate(indexedUnit(i))
+	}
+	if err := p.Wait(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(done)
+	pollWg.Wait()
+
+	if len(seen) != numUnits {
+		t.Fatalf("observed %d distinct units, want %d - a unit's metrics were evicted before being read", len(seen), numUnits)
+	}
+}
+
+// benchUnit is a minimal SourceUnit for benchmarking the chunker itself,
+// independent of any real source's enumeration/chunking cost.
+type benchUnit string
+
+func (b benchUnit) SourceUnitID() string { return string(b) }
+
+func benchUnits(n int) []SourceUnit {
+	units := make([]SourceUnit, n)
+	for i := range units {
+		units[i] = benchUnit(fmt.Sprintf("unit-%d", i))
+	}
+	return units
+}
+
+// benchChunkFn stands in for a real source's chunking work - reading a git
+// blob, paginating an API, etc - with a small fixed amount of latency, so
+// the benchmark reflects a scan dominated by many small, slightly-slow
+// units rather than pure scheduling overhead.
+func benchChunkFn(_ context.Context, unit SourceUnit, report func(*Chunk)) error {
+	time.Sleep(100 * time.Microsecond)
+	report(&Chunk{Data: []byte(unit.SourceUnitID())})
+	return nil
+}
+
+func BenchmarkParallelChunkerSerial(b *testing.B) {
+	ctx := context.Background()
+	units := benchUnits(4000)
+
+	for i := 0; i < b.N; i++ {
+		// A threshold above the unit count means the scaler never kicks in,
+		// so everything runs through the one base worker - the serial path.
+		p := NewParallelChunker(ctx, JobProgressRef{}, NoopHook{}, benchChunkFn, WithChunkerThreshold(len(units)+1))
+		for _, unit := range units {
+			p.Enumerate(unit)
+		}
+		if err := p.Wait(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParallelChunkerParallel(b *testing.B) {
+	ctx := context.Background()
+	units := benchUnits(4000)
+
+	for i := 0; i < b.N; i++ {
+		p := NewParallelChunker(ctx, JobProgressRef{}, NoopHook{}, benchChunkFn,
+			WithChunkerThreshold(64), WithChunkerConcurrency(8))
+		for _, unit := range units {
+			p.Enumerate(unit)
+		}
+		if err := p.Wait(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package sources
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/trufflesecurity/trufflehog/v3/pkg/context"
+)
+
+// PrometheusExporter periodically scrapes a UnitHook and publishes its
+// metrics on a prometheus.Registerer:
+//
+//   - trufflehog_unit_chunks_total{source,job}
+//   - trufflehog_unit_bytes_total{source,job}
+//   - trufflehog_unit_duration_seconds{source,job} (histogram)
+//   - trufflehog_unit_errors_total{source,job,kind}
+//   - trufflehog_units_inflight{source,job}
+//
+// Pair it with a UnitHook created via WithPrometheusRegisterer to also get
+// trufflehog_unit_evictions_total, which surfaces the silent data-loss case
+// where a unit's metrics are dropped from the cache before ever being read:
+//
+//	reg := prometheus.NewRegistry()
+//	hook := sources.NewUnitHook(ctx, sources.WithPrometheusRegisterer(reg))
+//	exporter := sources.NewPrometheusExporter(hook, reg)
+//	exporter.Start(ctx, 15*time.Second)
+//	defer exporter.Stop()
+//	http.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+type PrometheusExporter struct {
+	hook *UnitHook
+
+	chunks   *prometheus.CounterVec
+	bytes    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+	inflight *prometheus.GaugeVec
+
+	mu           sync.Mutex
+	seen         map[string]seenUnit
+	prevInflight map[[2]string]struct{}
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// seenUnit is the last scrape's values for a unit still being chunked, so a
+// later scrape of the same in-progress unit adds only the delta to the
+// monotonic counters instead of double counting. Entries are removed as
+// soon as a unit finishes or stops showing up in UnitMetrics() at all (e.g.
+// evicted while still in progress), so seen never outlives the units it
+// tracks.
+type seenUnit struct {
+	chunks uint64
+	bytes  uint64
+	errors int
+}
+
+// NewPrometheusExporter creates a PrometheusExporter that publishes hook's
+// metrics on reg. Call Start to begin scraping.
+func NewPrometheusExporter(hook *UnitHook, reg prometheus.Registerer) *PrometheusExporter {
+	e := &PrometheusExporter{
+		hook: hook,
+		chunks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "trufflehog_unit_chunks_total",
+			Help: "Total number of chunks produced per source unit.",
+		}, []string{"source", "job"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "trufflehog_unit_bytes_total",
+			Help: "Total number of bytes produced per source unit.",
+		}, []string{"source", "job"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "trufflehog_unit_duration_seconds",
+			Help: "Time to chunk a single source unit.",
+		}, []string{"source", "job"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "trufflehog_unit_errors_total",
+			Help: "Total number of errors encountered chunking source units.",
+		}, []string{"source", "job", "kind"}),
+		inflight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "trufflehog_units_inflight",
+			Help: "Number of source units currently being chunked.",
+		}, []string{"source", "job"}),
+		seen:         make(map[string]seenUnit),
+		prevInflight: make(map[[2]string]struct{}),
+	}
+	reg.MustRegister(e.chunks, e.bytes, e.duration, e.errors, e.inflight)
+	return e
+}
+
+// Start begins scraping the UnitHook every interval, until ctx is done or
+// Stop is called.
+func (e *PrometheusExporter) Start(ctx context.Context, interval time.Duration) {
+	e.stop = make(chan struct{})
+	e.stopped = make(chan struct{})
+	go func() {
+		defer close(e.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-e.stop:
+				return
+			case <-ticker.C:
+				e.scrape()
+			}
+		}
+	}()
+}
+
+// Stop halts scraping and waits for any in-flight scrape to finish.
+func (e *PrometheusExporter) Stop() {
+	if e.stop == nil {
+		return
+	}
+	close(e.stop)
+	<-e.stopped
+}
+
+// scrape reads a snapshot of the hook's metrics - UnitHook.UnitMetrics only
+// holds its mutex for the duration of the copy - and serializes it into
+// Prometheus metrics afterward, so a slow collector never blocks
+// StartUnitChunking/ReportChunk/EndUnitChunking running on other
+// goroutines.
+func (e *PrometheusExporter) scrape() {
+	metrics := e.hook.UnitMetrics()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	present := make(map[string]struct{}, len(metrics))
+	inflight := make(map[[2]string]int, len(e.prevInflight))
+	for _, m := range metrics {
+		source := strconv.FormatInt(int64(m.Parent.SourceID), 10)
+		job := strconv.FormatInt(int64(m.Parent.JobID), 10)
+		labels := prometheus.Labels{"source": source, "job": job}
+
+		id := e.hook.id(m.Parent, m.Unit)
+		present[id] = struct{}{}
+		prev := e.seen[id]
+
+		// A shared nil-unit ("non-unit source") entry can be LRU-evicted
+		// out of UnitHook mid-job and then recreated from scratch by the
+		// next ReportChunk under the same id, with its counters and Errors
+		// back at zero. Treat that as a new, unseen entry instead of
+		// computing a delta against the stale one - otherwise the "delta"
+		// goes negative and underflows as a uint64, and slicing
+		// m.Errors[prev.errors:] can panic once prev.errors is now out of
+		// range.
+		if m.TotalChunks < prev.chunks || m.TotalBytes < prev.bytes || len(m.Errors) < prev.errors {
+			prev = seenUnit{}
+		}
+
+		if delta := m.TotalChunks - prev.chunks; delta > 0 {
+			e.chunks.With(labels).Add(float64(delta))
+		}
+		if delta := m.TotalBytes - prev.bytes; delta > 0 {
+			e.bytes.With(labels).Add(float64(delta))
+		}
+		for _, unitErr := range m.Errors[prev.errors:] {
+			kind := "other"
+			var chunkErr ChunkError
+			if errors.As(unitErr, &chunkErr) {
+				kind = "chunk"
+			}
+			e.errors.With(prometheus.Labels{"source": source, "job": job, "kind": kind}).Inc()
+		}
+
+		if m.IsFinished() {
+			e.duration.With(labels).Observe(m.ElapsedTime().Seconds())
+			delete(e.seen, id)
+			continue
+		}
+
+		key := [2]string{source, job}
+		inflight[key]++
+		e.seen[id] = seenUnit{chunks: m.TotalChunks, bytes: m.TotalBytes, errors: len(m.Errors)}
+	}
+
+	// A seen entry whose unit didn't show up in this scrape at all - most
+	// likely evicted from UnitHook's cache while still in progress - would
+	// otherwise never be cleaned up, leaking one map entry per such unit
+	// for the life of the exporter.
+	for id := range e.seen {
+		if _, ok := present[id]; !ok {
+			delete(e.seen, id)
+		}
+	}
+
+	for key := range e.prevInflight {
+		if _, ok := inflight[key]; !ok {
+			e.inflight.With(prometheus.Labels{"source": key[0], "job": key[1]}).Set(0)
+		}
+	}
+	for key, count := range inflight {
+		e.inflight.With(prometheus.Labels{"source": key[0], "job": key[1]}).Set(float64(count))
+	}
+	e.prevInflight = make(map[[2]string]struct{}, len(inflight))
+	for key := range inflight {
+		e.prevInflight[key] = struct{}{}
+	}
+}